@@ -11,6 +11,7 @@ import (
 	"github.com/alecthomas/kingpin"
 	"github.com/apex/log"
 	"github.com/develar/app-builder/pkg/appimage"
+	"github.com/develar/app-builder/pkg/archlinux"
 	"github.com/develar/app-builder/pkg/blockmap"
 	"github.com/develar/app-builder/pkg/dmg"
 	"github.com/develar/app-builder/pkg/download"
@@ -21,6 +22,7 @@ import (
 	"github.com/develar/app-builder/pkg/log-cli"
 	"github.com/develar/app-builder/pkg/nodeModules"
 	"github.com/develar/app-builder/pkg/snap"
+	"github.com/develar/app-builder/pkg/tarexport"
 	"github.com/develar/app-builder/pkg/util"
 	"github.com/develar/errors"
 )
@@ -53,6 +55,8 @@ func main() {
 	ConfigureCopyCommand(app)
 	appimage.ConfigureCommand(app)
 	snap.ConfigureCommand(app)
+	archlinux.ConfigureCommand(app)
+	tarexport.ConfigureCommand(app)
 	icons.ConfigureCommand(app)
 	dmg.ConfigureCommand(app)
 	elfExecStack.ConfigureCommand(app)
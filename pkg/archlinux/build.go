@@ -0,0 +1,148 @@
+package archlinux
+
+import (
+	"archive/tar"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/develar/errors"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Build walks appDir and writes a pacman-compatible package to output: .PKGINFO, .MTREE, an
+// optional .INSTALL and the staged files themselves, as a zstd-compressed tar.
+func Build(appDir string, output string, info PackageInfo) error {
+	files, installedSize, err := walkStagingDir(appDir)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	mtree, err := writeMtree(files)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(output), 0777); err != nil {
+		return errors.WithStack(err)
+	}
+
+	outFile, err := os.Create(output)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer outFile.Close()
+
+	zstdWriter, err := zstd.NewWriter(outFile)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer zstdWriter.Close()
+
+	tarWriter := tar.NewWriter(zstdWriter)
+	defer tarWriter.Close()
+
+	if err := writeTarEntry(tarWriter, ".PKGINFO", writePkgInfo(info, installedSize)); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := writeTarEntry(tarWriter, ".MTREE", mtree); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if info.InstallScript != "" {
+		data, err := ioutil.ReadFile(info.InstallScript)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if err := writeTarEntry(tarWriter, ".INSTALL", data); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	for _, file := range files {
+		if err := writeStagedFile(tarWriter, file); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return nil
+}
+
+func writeTarEntry(w *tar.Writer, name string, data []byte) error {
+	if err := w.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data))}); err != nil {
+		return errors.WithStack(err)
+	}
+	_, err := w.Write(data)
+	return errors.WithStack(err)
+}
+
+func writeStagedFile(w *tar.Writer, file stagedFile) error {
+	link := ""
+	if file.info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(file.absPath)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		link = target
+	}
+
+	header, err := tar.FileInfoHeader(file.info, link)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	header.Name = file.relPath
+	header.Uid = 0
+	header.Gid = 0
+	header.Uname = "root"
+	header.Gname = "root"
+
+	if err := w.WriteHeader(header); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if link != "" || file.info.IsDir() {
+		return nil
+	}
+
+	src, err := os.Open(file.absPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer src.Close()
+
+	_, err = io.Copy(w, src)
+	return errors.WithStack(err)
+}
+
+func walkStagingDir(root string) ([]stagedFile, int64, error) {
+	var files []stagedFile
+	var installedSize int64
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, stagedFile{relPath: relPath, absPath: path, info: info})
+		if !info.IsDir() {
+			installedSize += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, errors.WithStack(err)
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].relPath < files[j].relPath })
+	return files, installedSize, nil
+}
@@ -0,0 +1,61 @@
+package archlinux
+
+import (
+	"github.com/alecthomas/kingpin"
+	"github.com/develar/app-builder/pkg/blockmap"
+	"github.com/develar/app-builder/pkg/util"
+	"github.com/develar/errors"
+)
+
+func ConfigureCommand(app *kingpin.Application) {
+	command := app.Command("archlinux", "Generate a pacman package (.pkg.tar.zst) from a staged app directory.")
+
+	appDir := command.Flag("app", "The staged application directory.").Required().String()
+	output := command.Flag("output", "The output package file, e.g. foo-1.2.3-1-x86_64.pkg.tar.zst.").Required().String()
+
+	name := command.Flag("name", "Package name.").Required().String()
+	pkgVer := command.Flag("pkgver", "Upstream version.").Required().String()
+	pkgRel := command.Flag("pkgrel", "Package release.").Default("1").String()
+	arch := command.Flag("arch", "Target architecture.").Default("x86_64").String()
+	license := command.Flag("license", "License identifier (repeatable).").Strings()
+	url := command.Flag("url", "Upstream project URL.").String()
+	description := command.Flag("description", "Package description.").String()
+	depends := command.Flag("depends", "Runtime dependency, e.g. glibc>=2.30 (repeatable).").Strings()
+	optDepends := command.Flag("optdepends", "Optional dependency, e.g. \"pkg: reason\" (repeatable).").Strings()
+	conflicts := command.Flag("conflicts", "Conflicting package (repeatable).").Strings()
+	provides := command.Flag("provides", "Provided package or virtual package (repeatable).").Strings()
+	installScript := command.Flag("install-script", "Path to a pacman .install script.").String()
+	backup := command.Flag("backup", "Config file path to mark as a backup (repeatable).").Strings()
+
+	command.Action(func(context *kingpin.ParseContext) error {
+		info := PackageInfo{
+			Name:          *name,
+			Version:       *pkgVer,
+			Release:       *pkgRel,
+			Arch:          *arch,
+			License:       *license,
+			Url:           *url,
+			Description:   *description,
+			Depends:       *depends,
+			OptDepends:    *optDepends,
+			Conflicts:     *conflicts,
+			Provides:      *provides,
+			InstallScript: *installScript,
+			Backup:        *backup,
+		}
+
+		if err := info.Validate(); err != nil {
+			return errors.WithStack(err)
+		}
+
+		if err := Build(*appDir, *output, info); err != nil {
+			return errors.WithStack(err)
+		}
+
+		inputInfo, err := blockmap.BuildBlockMap(*output, blockmap.DefaultChunkerConfiguration, blockmap.GZIP, *output+".blockmap")
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		return util.WriteJsonToStdOut(inputInfo)
+	})
+}
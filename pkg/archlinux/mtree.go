@@ -0,0 +1,79 @@
+package archlinux
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/develar/errors"
+)
+
+type stagedFile struct {
+	relPath string
+	absPath string
+	info    os.FileInfo
+}
+
+// writeMtree renders the gzip-compressed .MTREE member describing every staged file: mode, uid,
+// gid, size, type and sha256digest. Staged files are always packaged as owned by root.
+func writeMtree(files []stagedFile) ([]byte, error) {
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+
+	fmt.Fprintln(gzipWriter, "#mtree")
+	for _, file := range files {
+		entryType := "file"
+		switch {
+		case file.info.IsDir():
+			entryType = "dir"
+		case file.info.Mode()&os.ModeSymlink != 0:
+			entryType = "link"
+		}
+
+		line := fmt.Sprintf("./%s time=%d.0 mode=%o type=%s uid=0 gid=0", file.relPath, file.info.ModTime().Unix(), file.info.Mode().Perm(), entryType)
+
+		switch entryType {
+		case "file":
+			digest, size, err := sha256File(file.absPath)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			line += fmt.Sprintf(" size=%d sha256digest=%s", size, digest)
+
+		case "link":
+			target, err := os.Readlink(file.absPath)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			line += fmt.Sprintf(" link=%s", target)
+		}
+
+		if _, err := fmt.Fprintln(gzipWriter, line); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	if err := gzipWriter.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return buf.Bytes(), nil
+}
+
+func sha256File(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, errors.WithStack(err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return "", 0, errors.WithStack(err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
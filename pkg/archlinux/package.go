@@ -0,0 +1,47 @@
+package archlinux
+
+import (
+	"regexp"
+
+	"github.com/develar/errors"
+)
+
+var nameRegexp = regexp.MustCompile(`^[a-zA-Z0-9@._+-]+$`)
+var versionedDependRegexp = regexp.MustCompile(`^[a-zA-Z0-9@._+-]+([<>]?=?([0-9]+:)?[a-zA-Z0-9@._+-]+)?$`)
+
+// PackageInfo is the pacman-facing metadata for one .pkg.tar.zst.
+type PackageInfo struct {
+	Name        string
+	Version     string
+	Release     string
+	Arch        string
+	License     []string
+	Url         string
+	Description string
+
+	Depends    []string
+	OptDepends []string
+	Conflicts  []string
+	Provides   []string
+
+	InstallScript string
+	Backup        []string
+}
+
+// Validate checks Name and every versioned-dependency-shaped field (Depends/Conflicts/Provides)
+// against the same patterns pacman itself enforces.
+func (info PackageInfo) Validate() error {
+	if !nameRegexp.MatchString(info.Name) {
+		return errors.Errorf("invalid package name %q", info.Name)
+	}
+
+	for _, group := range [][]string{info.Depends, info.Conflicts, info.Provides} {
+		for _, value := range group {
+			if !versionedDependRegexp.MatchString(value) {
+				return errors.Errorf("invalid dependency specifier %q", value)
+			}
+		}
+	}
+
+	return nil
+}
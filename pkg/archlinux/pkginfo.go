@@ -0,0 +1,46 @@
+package archlinux
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// writePkgInfo renders the .PKGINFO member: one "key = value" line per metadata field.
+func writePkgInfo(info PackageInfo, installedSize int64) []byte {
+	var buf bytes.Buffer
+	writeField := func(key, value string) {
+		if value != "" {
+			fmt.Fprintf(&buf, "%s = %s\n", key, value)
+		}
+	}
+
+	fmt.Fprintln(&buf, "# Generated by app-builder")
+	writeField("pkgname", info.Name)
+	writeField("pkgbase", info.Name)
+	writeField("pkgver", fmt.Sprintf("%s-%s", info.Version, info.Release))
+	writeField("pkgdesc", info.Description)
+	writeField("url", info.Url)
+	fmt.Fprintf(&buf, "size = %d\n", installedSize)
+	writeField("arch", info.Arch)
+
+	for _, license := range info.License {
+		writeField("license", license)
+	}
+	for _, depend := range info.Depends {
+		writeField("depend", depend)
+	}
+	for _, optDepend := range info.OptDepends {
+		writeField("optdepend", optDepend)
+	}
+	for _, conflict := range info.Conflicts {
+		writeField("conflict", conflict)
+	}
+	for _, provide := range info.Provides {
+		writeField("provides", provide)
+	}
+	for _, backup := range info.Backup {
+		writeField("backup", backup)
+	}
+
+	return buf.Bytes()
+}
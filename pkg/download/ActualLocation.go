@@ -3,8 +3,10 @@ package download
 import (
 	"crypto/sha512"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 
 	"github.com/apex/log"
@@ -15,12 +17,19 @@ import (
 
 // ActualLocation represents server's status 200 or 206 response meta data. It never holds redirect responses
 type ActualLocation struct {
-	Url            string
+	Url string
+	// OriginalUrl is the URL before any redirects were followed.
+	OriginalUrl    string
 	OutFileName    string
 	isAcceptRanges bool
 	StatusCode     int
 	ContentLength  int64
+	ETag           string
+	LastModified   string
 	Parts          []*Part
+
+	// resumeState is the download-state sidecar loaded for this location, if any.
+	resumeState *downloadState
 }
 
 func NewResolvedLocation(url string, contentLength int64, outFileName string, isAcceptRanges bool) ActualLocation {
@@ -32,6 +41,104 @@ func NewResolvedLocation(url string, contentLength int64, outFileName string, is
 	}
 }
 
+// downloadState is the sidecar persisted next to the output file describing an in-progress download.
+type downloadState struct {
+	Url           string          `json:"url"`
+	OriginalUrl   string          `json:"originalUrl"`
+	ContentLength int64           `json:"contentLength"`
+	ETag          string          `json:"etag,omitempty"`
+	LastModified  string          `json:"lastModified,omitempty"`
+	Parts         []partStateInfo `json:"parts"`
+}
+
+type partStateInfo struct {
+	Name    string `json:"name"`
+	Start   int64  `json:"start"`
+	End     int64  `json:"end"`
+	Written int64  `json:"written"`
+}
+
+func stateFilePath(outFileName string) string {
+	return outFileName + ".download-state.json"
+}
+
+func loadDownloadState(outFileName string) (*downloadState, error) {
+	data, err := ioutil.ReadFile(stateFilePath(outFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+
+	state := &downloadState{}
+	err = json.Unmarshal(data, state)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return state, nil
+}
+
+func removeDownloadState(outFileName string) {
+	err := os.Remove(stateFilePath(outFileName))
+	if err != nil && !os.IsNotExist(err) {
+		log.WithError(err).Warn("cannot remove download state file")
+	}
+}
+
+// saveState persists the current part layout.
+func (actualLocation *ActualLocation) saveState() error {
+	state := downloadState{
+		Url:           actualLocation.Url,
+		OriginalUrl:   actualLocation.OriginalUrl,
+		ContentLength: actualLocation.ContentLength,
+		ETag:          actualLocation.ETag,
+		LastModified:  actualLocation.LastModified,
+		Parts:         make([]partStateInfo, len(actualLocation.Parts)),
+	}
+
+	for i, part := range actualLocation.Parts {
+		state.Parts[i] = partStateInfo{Name: part.Name, Start: part.Start, End: part.End, Written: part.Written}
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(ioutil.WriteFile(stateFilePath(actualLocation.OutFileName), data, 0666))
+}
+
+// restoreParts matches previously persisted parts by name/range and restores how many bytes were
+// already written for any whose file still exists on disk.
+func (actualLocation *ActualLocation) restoreParts(state *downloadState) {
+	if state == nil {
+		return
+	}
+
+	byName := make(map[string]partStateInfo, len(state.Parts))
+	for _, p := range state.Parts {
+		byName[p.Name] = p
+	}
+
+	for _, part := range actualLocation.Parts {
+		saved, ok := byName[part.Name]
+		if !ok || saved.Start != part.Start || saved.End != part.End {
+			continue
+		}
+
+		info, err := os.Stat(part.Name)
+		if err != nil {
+			continue
+		}
+
+		written := info.Size()
+		if length := part.length(); length >= 0 && written > length {
+			written = length
+		}
+		part.Written = written
+	}
+}
+
 func (actualLocation *ActualLocation) computeParts(minPartSize int64) {
 	if actualLocation.ContentLength < 0 {
 		log.WithField("length", actualLocation.ContentLength).Warn("invalid content length, will be downloaded as one part")
@@ -63,7 +170,7 @@ func (actualLocation *ActualLocation) computeParts(minPartSize int64) {
 	start := int64(0)
 	for i := 0; i < partCount; i++ {
 		end := start + partSize
-		if end > contentLength || i == (partCount - 1) {
+		if end > contentLength || i == (partCount-1) {
 			end = contentLength
 		}
 
@@ -104,6 +211,7 @@ func (actualLocation *ActualLocation) concatenateParts(expectedSha512 string) er
 		}
 	} else {
 		if len(actualLocation.Parts) == 1 {
+			removeDownloadState(actualLocation.OutFileName)
 			return nil
 		}
 
@@ -162,5 +270,6 @@ func (actualLocation *ActualLocation) concatenateParts(expectedSha512 string) er
 		}
 	}
 
+	removeDownloadState(actualLocation.OutFileName)
 	return nil
 }
@@ -0,0 +1,96 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/apex/log"
+	"github.com/develar/app-builder/pkg/util"
+	"github.com/develar/errors"
+)
+
+// Part is one range of the remote file, downloaded into its own file on disk.
+type Part struct {
+	Name string
+
+	Start int64
+	End   int64
+
+	// Skip marks a part that must be dropped (not concatenated) once the download finishes.
+	Skip bool
+
+	// Written is how many bytes of this part are already on disk. When a matching download-state
+	// sidecar exists, it starts as the size of the part file found on disk (see restoreParts).
+	Written int64
+
+	isFail bool
+}
+
+func (part *Part) length() int64 {
+	if part.End < 0 {
+		return -1
+	}
+	return part.End - part.Start
+}
+
+func (part *Part) isComplete() bool {
+	length := part.length()
+	return length >= 0 && part.Written >= length
+}
+
+func (part *Part) download(context context.Context, url string, index int, client *http.Client, reporter ProgressReporter) error {
+	if part.isComplete() {
+		log.WithFields(log.Fields{"id": index, "file": part.Name}).Debug("part already downloaded, skipping")
+		return nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY
+	if part.End >= 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", part.Start+part.Written, part.End-1))
+		if part.Written > 0 {
+			flags |= os.O_APPEND
+		} else {
+			flags |= os.O_TRUNC
+		}
+	} else {
+		flags |= os.O_TRUNC
+	}
+
+	response, err := client.Do(req.WithContext(context))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer util.Close(response.Body)
+
+	if response.StatusCode >= 400 {
+		return errors.Errorf("cannot download part %d (%s): status code %d", index, part.Name, response.StatusCode)
+	}
+
+	if part.Written > 0 && response.StatusCode != http.StatusPartialContent {
+		// the server ignored our Range request, so the part file we have on disk is no longer valid
+		part.Written = 0
+		flags = os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	}
+
+	file, err := os.OpenFile(part.Name, flags, 0666)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer util.Close(file)
+
+	written, err := io.Copy(file, &progressReader{reader: response.Body, index: index, reporter: reporter})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	part.Written += written
+	return nil
+}
@@ -0,0 +1,30 @@
+package download
+
+import (
+	"context"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/develar/errors"
+)
+
+// ConfigureArtifactCommand registers "download-artifact", the single-file counterpart of
+// download-resolved-files: it resolves and downloads exactly one URL without a batch manifest or
+// picking an external backend.
+func ConfigureArtifactCommand(app *kingpin.Application) {
+	command := app.Command("download-artifact", "Download a single already-resolved file.")
+	fileUrl := command.Flag("url", "The URL.").Short('u').Required().String()
+	output := command.Flag("output", "The output file.").Short('o').Required().String()
+	sha512 := command.Flag("sha512", "The expected sha512 of file.").String()
+	progressKind := addProgressFlag(command)
+	progressFd := addProgressFdFlag(command)
+
+	command.Action(func(context *kingpin.ParseContext) error {
+		downloader := NewDownloader().WithProgress(configureProgressReporter(*progressKind, *progressFd))
+		return errors.WithStack(downloadArtifact(downloader, ResolvedFile{Url: *fileUrl, Output: *output, Sha512: *sha512}))
+	})
+}
+
+// downloadArtifact downloads a single file via the same path as one entry of download-resolved-files.
+func downloadArtifact(downloader Downloader, file ResolvedFile) error {
+	return downloader.downloadResolvedFile(context.Background(), file, nil)
+}
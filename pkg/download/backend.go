@@ -0,0 +1,113 @@
+package download
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/develar/errors"
+	"github.com/mitchellh/go-homedir"
+)
+
+// Backend abstracts how a single URL is fetched to a local file. The default is this package's own
+// multipart HTTP downloader, but very large artifacts, or .torrent/magnet: URLs, can be routed
+// through a pluggable backend that hands the download to an already running external download
+// manager instead.
+type Backend interface {
+	Download(url string, output string, sha512 string) error
+}
+
+// builtinBackend adapts the existing multipart HTTP Downloader to the Backend interface.
+type builtinBackend struct {
+	downloader Downloader
+}
+
+func (b builtinBackend) Download(url string, output string, sha512 string) error {
+	return b.downloader.Download(url, output, sha512)
+}
+
+// backendConfig is the optional ~/.config/app-builder/downloaders.json, providing defaults for the
+// --aria2-rpc/--qb-url/--qb-auth flags so they don't have to be repeated on every invocation.
+type backendConfig struct {
+	Aria2Rpc string `json:"aria2Rpc"`
+	QbUrl    string `json:"qbUrl"`
+	QbAuth   string `json:"qbAuth"`
+}
+
+func loadBackendConfig() backendConfig {
+	home, err := homedir.Dir()
+	if err != nil {
+		return backendConfig{}
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(home, ".config", "app-builder", "downloaders.json"))
+	if err != nil {
+		return backendConfig{}
+	}
+
+	var config backendConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		log.WithError(err).Warn("cannot parse downloaders.json, ignoring")
+		return backendConfig{}
+	}
+	return config
+}
+
+func isTorrentUrl(rawUrl string) bool {
+	return strings.HasPrefix(rawUrl, "magnet:") || strings.HasSuffix(strings.ToLower(rawUrl), ".torrent")
+}
+
+// selectBackend resolves --backend (falling back to ~/.config/app-builder/downloaders.json for any
+// empty --aria2-rpc/--qb-url/--qb-auth flag). kind "" or "auto" picks qbittorrent for magnet/.torrent
+// URLs, aria2 once rawUrl's size is known to exceed sizeThreshold (0 disables the check), and the
+// builtin downloader otherwise.
+func selectBackend(kind string, rawUrl string, sizeThreshold int64, downloader Downloader, aria2Rpc string, qbUrl string, qbAuth string) (Backend, error) {
+	config := loadBackendConfig()
+	if aria2Rpc == "" {
+		aria2Rpc = config.Aria2Rpc
+	}
+	if qbUrl == "" {
+		qbUrl = config.QbUrl
+	}
+	if qbAuth == "" {
+		qbAuth = config.QbAuth
+	}
+
+	if kind == "" || kind == "auto" {
+		switch {
+		case isTorrentUrl(rawUrl):
+			kind = "qbittorrent"
+		case sizeThreshold > 0:
+			location, err := downloader.follow(rawUrl, userAgent, "")
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			if location.ContentLength > sizeThreshold {
+				kind = "aria2"
+			} else {
+				kind = "builtin"
+			}
+		default:
+			kind = "builtin"
+		}
+	}
+
+	switch kind {
+	case "builtin":
+		return builtinBackend{downloader: downloader}, nil
+	case "aria2":
+		if aria2Rpc == "" {
+			return nil, errors.Errorf("--aria2-rpc (or aria2Rpc in downloaders.json) is required for --backend=aria2")
+		}
+		return newAria2Backend(aria2Rpc), nil
+	case "qbittorrent":
+		if qbUrl == "" {
+			return nil, errors.Errorf("--qb-url (or qbUrl in downloaders.json) is required for --backend=qbittorrent")
+		}
+		return newQbittorrentBackend(qbUrl, qbAuth), nil
+	default:
+		return nil, errors.Errorf("unknown backend %q", kind)
+	}
+}
@@ -0,0 +1,38 @@
+package download
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"io"
+	"os"
+
+	"github.com/develar/errors"
+	"github.com/develar/go-fs-util"
+)
+
+// verifyFileSha512 checks that the file at path hashes to expectedBase64, doing nothing if
+// expectedBase64 is empty (no checksum was requested). Used by backends that can't stream the
+// verification alongside the download itself, e.g. because a third-party tool wrote the file.
+func verifyFileSha512(path string, expectedBase64 string) error {
+	if expectedBase64 == "" {
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	hash := sha512.New()
+	_, err = io.Copy(hash, file)
+	err = fsutil.CloseAndCheckError(err, file)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	actual := base64.StdEncoding.EncodeToString(hash.Sum(nil))
+	if actual != expectedBase64 {
+		return errors.Errorf("sha512 checksum mismatch, expected %s, got %s", expectedBase64, actual)
+	}
+	return nil
+}
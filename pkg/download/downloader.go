@@ -9,6 +9,8 @@ import (
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -22,9 +24,10 @@ import (
 
 //noinspection SpellCheckingInspection
 const (
-	maxRedirects = 10
-	minPartSize  = 5 * 1024 * 1024
-	userAgent    = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_13_3) AppleWebKit/604.5.6 (KHTML, like Gecko) Version/11.0.3 Safari/604.5.6"
+	maxRedirects        = 10
+	minPartSize         = 5 * 1024 * 1024
+	maxIdleConnsPerHost = 64
+	userAgent           = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_13_3) AppleWebKit/604.5.6 (KHTML, like Gecko) Version/11.0.3 Safari/604.5.6"
 )
 
 func getMaxPartCount() int {
@@ -42,22 +45,60 @@ func ConfigureCommand(app *kingpin.Application) {
 	fileUrl := command.Flag("url", "The URL.").Short('u').Required().String()
 	output := command.Flag("output", "The output file.").Short('o').Required().String()
 	sha512 := command.Flag("sha512", "The expected sha512 of file.").String()
+	progressKind := addProgressFlag(command)
+	progressFd := addProgressFdFlag(command)
+	backendKind := command.Flag("backend", "Which download backend to use.").Default("auto").Enum("auto", "builtin", "aria2", "qbittorrent")
+	backendSizeThreshold := command.Flag("backend-size-threshold", "With --backend=auto, switch to aria2 once the file is known to be larger than this many bytes (0 disables the check).").Int64()
+	aria2Rpc := command.Flag("aria2-rpc", "aria2 JSON-RPC endpoint, e.g. http://localhost:6800/jsonrpc.").String()
+	qbUrl := command.Flag("qb-url", "qBittorrent Web UI base URL, e.g. http://localhost:8080.").String()
+	qbAuth := command.Flag("qb-auth", "qBittorrent Web UI credentials as user:pass.").String()
 
 	command.Action(func(context *kingpin.ParseContext) error {
-		return errors.WithStack(NewDownloader().Download(*fileUrl, *output, *sha512))
+		downloader := NewDownloader().WithProgress(configureProgressReporter(*progressKind, *progressFd))
+		backend, err := selectBackend(*backendKind, *fileUrl, *backendSizeThreshold, downloader, *aria2Rpc, *qbUrl, *qbAuth)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		return errors.WithStack(backend.Download(*fileUrl, *output, *sha512))
 	})
 }
 
+// addProgressFlag registers the --progress flag shared by the download commands.
+func addProgressFlag(command *kingpin.CmdClause) *string {
+	return command.Flag("progress", "Progress reporting style.").Default("none").Enum("json", "bar", "none")
+}
+
+// addProgressFdFlag registers the --progress-fd flag shared by the download commands.
+func addProgressFdFlag(command *kingpin.CmdClause) *int {
+	return command.Flag("progress-fd", "File descriptor to write JSON progress events to (only used when --progress=json).").Int()
+}
+
+// configureProgressReporter turns --progress/--progress-fd into a ProgressReporter.
+func configureProgressReporter(kind string, fd int) ProgressReporter {
+	switch kind {
+	case "bar":
+		return NewBarProgressReporter(os.Stderr)
+	case "json":
+		if fd <= 0 {
+			return NoopProgressReporter
+		}
+		return NewJsonProgressReporter(os.NewFile(uintptr(fd), "progress"))
+	default:
+		return NoopProgressReporter
+	}
+}
+
 type Downloader struct {
 	client    *http.Client
 	transport *http.Transport
+	progress  ProgressReporter
 }
 
 func NewDownloader() Downloader {
 	transport := &http.Transport{
 		Proxy:               proxyFromEnvironmentAndNpm,
 		MaxIdleConns:        64,
-		MaxIdleConnsPerHost: 64,
+		MaxIdleConnsPerHost: maxIdleConnsPerHost,
 		IdleConnTimeout:     30 * time.Second,
 	}
 	return Downloader{
@@ -68,37 +109,100 @@ func NewDownloader() Downloader {
 			},
 			Transport: transport,
 		},
+		progress: NoopProgressReporter,
 	}
 }
 
+// WithProgress returns a copy of the downloader that reports progress to reporter.
+func (t Downloader) WithProgress(reporter ProgressReporter) Downloader {
+	t.progress = reporter
+	return t
+}
+
 func (t Downloader) Download(url string, output string, sha512 string) error {
+	return t.DownloadWithContext(context.Background(), url, output, sha512)
+}
+
+// DownloadWithContext is Download, but the part downloads are also cancelled as soon as ctx is done.
+func (t Downloader) DownloadWithContext(ctx context.Context, url string, output string, sha512 string) error {
 	dir := filepath.Dir(output)
 	err := os.MkdirAll(dir, 0777)
 	if err != nil {
 		return errors.WithStack(err)
 	}
 
-	actualLocation, err := t.follow(url, userAgent, output)
+	actualLocation, err := t.resolveForDownload(url, output)
 	if err != nil {
 		return errors.WithStack(err)
 	}
 
-	return t.DownloadResolved(actualLocation, sha512)
+	return t.downloadResolved(ctx, actualLocation, sha512, getMaxPartCount(), nil)
+}
+
+// resolveForDownload follows url as usual, unless a download-state sidecar from a previous attempt
+// at the same output file and URL exists and its validator is still accepted by the server.
+func (t Downloader) resolveForDownload(url string, output string) (*ActualLocation, error) {
+	state, err := loadDownloadState(output)
+	if err != nil {
+		log.WithError(err).Warn("cannot read existing download state, starting over")
+		state = nil
+	}
+
+	if state == nil || state.OriginalUrl != url {
+		return t.follow(url, userAgent, output)
+	}
+
+	location, valid, err := t.followForResume(url, userAgent, output, state.ETag, state.LastModified)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if !valid || !location.isAcceptRanges || location.ContentLength != state.ContentLength {
+		log.Debug("cannot resume download (validator rejected or server doesn't support ranges), starting over")
+		removeDownloadState(output)
+		return t.follow(url, userAgent, output)
+	}
+
+	location.resumeState = state
+	return location, nil
 }
 
 func (t Downloader) DownloadResolved(location *ActualLocation, sha512 string) error {
-	downloadContext, cancel := context.WithCancel(context.Background())
+	return t.downloadResolved(context.Background(), location, sha512, getMaxPartCount(), nil)
+}
+
+// downloadResolved is the shared implementation behind DownloadResolved/DownloadWithContext and the
+// concurrent resolved-files path. maxParts bounds how many parts of this one artifact download at
+// the same time; hostBudget, if non-nil, is a semaphore shared with sibling artifacts on the same host.
+func (t Downloader) downloadResolved(ctx context.Context, location *ActualLocation, sha512 string, maxParts int, hostBudget chan struct{}) error {
+	downloadContext, cancel := context.WithCancel(ctx)
 	go onCancelSignal(cancel)
 
 	location.computeParts(minPartSize)
+	location.restoreParts(location.resumeState)
+	if err := location.saveState(); err != nil {
+		log.WithError(err).Warn("cannot persist download state")
+	}
+
 	log.WithFields(&log.Fields{
 		"url": location.Url,
 		"parts": len(location.Parts),
 	}).Debug("download")
-	err := util.MapAsyncConcurrency(len(location.Parts), getMaxPartCount(), func(index int) (func() error, error) {
+
+	t.progress.Start(location.ContentLength)
+	err := util.MapAsyncConcurrency(len(location.Parts), maxParts, func(index int) (func() error, error) {
 		part := location.Parts[index]
 		return func() error {
-			err := part.download(downloadContext, location.Url, index, t.client)
+			if hostBudget != nil {
+				select {
+				case hostBudget <- struct{}{}:
+					defer func() { <-hostBudget }()
+				case <-downloadContext.Done():
+					return errors.WithStack(downloadContext.Err())
+				}
+			}
+
+			err := part.download(downloadContext, location.Url, index, t.client, t.progress)
 			if err != nil {
 				part.isFail = true
 				log.WithFields(log.Fields{
@@ -111,6 +215,7 @@ func (t Downloader) DownloadResolved(location *ActualLocation, sha512 string) er
 	})
 
 	if err != nil {
+		t.progress.Done(err)
 		return errors.WithStack(err)
 	}
 
@@ -123,6 +228,7 @@ func (t Downloader) DownloadResolved(location *ActualLocation, sha512 string) er
 
 	location.deleteUnnecessaryParts()
 	err = location.concatenateParts(sha512)
+	t.progress.Done(err)
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -130,6 +236,80 @@ func (t Downloader) DownloadResolved(location *ActualLocation, sha512 string) er
 }
 
 func (t Downloader) follow(initialUrl, userAgent, outFileName string) (*ActualLocation, error) {
+	response, currentUrl, err := t.doFollow(initialUrl, userAgent, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("resolve request failed with status code %d", response.StatusCode)
+	}
+
+	actualLocation := NewResolvedLocation(currentUrl, response.ContentLength, outFileName, response.Header.Get("Accept-Ranges") != "")
+	actualLocation.OriginalUrl = initialUrl
+	actualLocation.ETag = response.Header.Get("ETag")
+	actualLocation.LastModified = response.Header.Get("Last-Modified")
+	var length string
+	if response.ContentLength < 0 {
+		length = "unknown"
+	} else {
+		length = fmt.Sprintf("%d", response.ContentLength)
+	}
+
+	log.WithFields(log.Fields{
+		"length":       length,
+		"content-type": response.Header.Get("Content-Type"),
+		"url":          initialUrl,
+	}).Debug("downloading")
+
+	if !actualLocation.isAcceptRanges {
+		log.Warn("server doesn't support ranges")
+	}
+	return &actualLocation, nil
+}
+
+// followForResume checks whether url still refers to the same content as when etag/lastModified were
+// recorded, using a conditional ranged request (If-Range). valid is false if the validator was
+// rejected or the server doesn't support it, in which case the caller must fall back to follow().
+func (t Downloader) followForResume(url, userAgent, outFileName, etag, lastModified string) (*ActualLocation, bool, error) {
+	if etag == "" && lastModified == "" {
+		return nil, false, nil
+	}
+
+	response, currentUrl, err := t.doFollow(url, userAgent, func(req *http.Request) {
+		req.Header.Set("Range", "bytes=0-0")
+		if etag != "" {
+			req.Header.Set("If-Range", etag)
+		} else {
+			req.Header.Set("If-Range", lastModified)
+		}
+	})
+	if err != nil {
+		return nil, false, errors.WithStack(err)
+	}
+
+	if response.StatusCode != http.StatusPartialContent {
+		// the validator was rejected (or the server ignored If-Range) - the file must be re-fetched
+		return nil, false, nil
+	}
+
+	total, err := parseContentRangeTotal(response.Header.Get("Content-Range"))
+	if err != nil {
+		log.WithError(err).Debug("cannot parse Content-Range, cannot resume")
+		return nil, false, nil
+	}
+
+	actualLocation := NewResolvedLocation(currentUrl, total, outFileName, true)
+	actualLocation.OriginalUrl = url
+	actualLocation.ETag = response.Header.Get("ETag")
+	actualLocation.LastModified = lastModified
+	return &actualLocation, true, nil
+}
+
+// doFollow issues a GET against initialUrl, following redirects, and returns the final non-redirect
+// response (body already drained and closed) along with the URL it came from. configureRequest, if
+// non-nil, sets extra headers on every request in the chain.
+func (t Downloader) doFollow(initialUrl, userAgent string, configureRequest func(*http.Request)) (*http.Response, string, error) {
 	currentUrl := initialUrl
 	redirectsFollowed := 0
 	for {
@@ -144,65 +324,48 @@ func (t Downloader) follow(initialUrl, userAgent, outFileName string) (*ActualLo
 		// https://stackoverflow.com/questions/3854842/content-length-header-with-head-requests
 		req, err := http.NewRequest(http.MethodGet, currentUrl, nil)
 		if err != nil {
-			return nil, errors.WithStack(err)
+			return nil, "", errors.WithStack(err)
 		}
 
 		req.Header.Set("User-Agent", userAgent)
-		actualLocation, err := func() (*ActualLocation, error) {
-			response, err := t.client.Do(req)
+		if configureRequest != nil {
+			configureRequest(req)
+		}
+
+		response, err := t.client.Do(req)
+		if err != nil {
 			if response != nil {
 				util.Close(response.Body)
 			}
+			return nil, "", errors.WithStack(err)
+		}
 
+		if isRedirect(response.StatusCode) {
+			loc, err := response.Location()
+			util.Close(response.Body)
 			if err != nil {
-				return nil, errors.WithStack(err)
-			}
-
-			if isRedirect(response.StatusCode) {
-				loc, err := response.Location()
-				if err != nil {
-					return nil, errors.WithStack(err)
-				}
-
-				currentUrl = loc.String()
-				return nil, nil
-			} else if response.StatusCode != http.StatusOK {
-				return nil, fmt.Errorf("resolve request failed with status code %d", response.StatusCode)
+				return nil, "", errors.WithStack(err)
 			}
 
-			actualLocation := NewResolvedLocation(currentUrl, response.ContentLength, outFileName, response.Header.Get("Accept-Ranges") != "")
-			var length string
-			if response.ContentLength < 0 {
-				length = "unknown"
-			} else {
-				length = fmt.Sprintf("%d", response.ContentLength)
+			currentUrl = loc.String()
+			redirectsFollowed++
+			if redirectsFollowed > maxRedirects {
+				return nil, "", errors.Errorf("maximum number of redirects (%d) followed", maxRedirects)
 			}
-
-			log.WithFields(log.Fields{
-				"length":       length,
-				"content-type": response.Header.Get("Content-Type"),
-				"url":          initialUrl,
-			}).Debug("downloading")
-
-			if !actualLocation.isAcceptRanges {
-				log.Warn("server doesn't support ranges")
-			}
-			return &actualLocation, nil
-		}()
-
-		if err != nil {
-			return nil, errors.WithStack(err)
+			continue
 		}
 
-		if actualLocation != nil {
-			return actualLocation, nil
-		}
+		util.Close(response.Body)
+		return response, currentUrl, nil
+	}
+}
 
-		redirectsFollowed++
-		if redirectsFollowed > maxRedirects {
-			return nil, errors.Errorf("maximum number of redirects (%d) followed", maxRedirects)
-		}
+func parseContentRangeTotal(contentRange string) (int64, error) {
+	slash := strings.LastIndexByte(contentRange, '/')
+	if slash < 0 {
+		return 0, errors.Errorf("invalid Content-Range header %q", contentRange)
 	}
+	return strconv.ParseInt(contentRange[slash+1:], 10, 64)
 }
 
 func onCancelSignal(cancel context.CancelFunc) {
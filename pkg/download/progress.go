@@ -0,0 +1,242 @@
+package download
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ProgressReporter receives updates about an in-progress download.
+type ProgressReporter interface {
+	Start(total int64)
+	Advance(partIndex int, delta int64)
+	Done(err error)
+}
+
+// progressEvent is the newline-delimited JSON shape emitted on the progress fd.
+type progressEvent struct {
+	Percent     float64 `json:"percent"`
+	Transferred int64   `json:"transferred"`
+	Total       int64   `json:"total"`
+	Speed       int64   `json:"speed"`
+	EtaSeconds  int64   `json:"etaSeconds,omitempty"`
+}
+
+// noopProgressReporter is used whenever progress reporting is disabled (the default).
+type noopProgressReporter struct{}
+
+func (noopProgressReporter) Start(int64)        {}
+func (noopProgressReporter) Advance(int, int64) {}
+func (noopProgressReporter) Done(error)         {}
+
+// NoopProgressReporter discards all progress updates.
+var NoopProgressReporter ProgressReporter = noopProgressReporter{}
+
+// jsonProgressReporter aggregates per-part Advance calls into one overall percent/bytes/speed stream.
+type jsonProgressReporter struct {
+	writer io.Writer
+
+	mutex     sync.Mutex
+	total     int64
+	startTime time.Time
+
+	transferred int64
+}
+
+// NewJsonProgressReporter reports progress as newline-delimited JSON objects written to writer.
+func NewJsonProgressReporter(writer io.Writer) ProgressReporter {
+	return &jsonProgressReporter{writer: writer}
+}
+
+func (r *jsonProgressReporter) Start(total int64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.total = total
+	if r.startTime.IsZero() {
+		r.startTime = time.Now()
+	}
+}
+
+func (r *jsonProgressReporter) Advance(_ int, delta int64) {
+	transferred := atomic.AddInt64(&r.transferred, delta)
+
+	r.mutex.Lock()
+	total := r.total
+	elapsed := time.Since(r.startTime).Seconds()
+	r.mutex.Unlock()
+
+	event := progressEvent{Transferred: transferred, Total: total}
+	if total > 0 {
+		event.Percent = float64(transferred) / float64(total) * 100
+	}
+	if elapsed > 0 {
+		event.Speed = int64(float64(transferred) / elapsed)
+	}
+	if event.Speed > 0 && total > transferred {
+		event.EtaSeconds = (total - transferred) / event.Speed
+	}
+
+	r.emit(event)
+}
+
+func (r *jsonProgressReporter) Done(err error) {
+	if err == nil && r.total > 0 {
+		r.emit(progressEvent{Percent: 100, Transferred: r.total, Total: r.total})
+	}
+}
+
+func (r *jsonProgressReporter) emit(event progressEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	data = append(data, '\n')
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	_, _ = r.writer.Write(data)
+}
+
+// barProgressReporter renders a single-line terminal progress bar, overwritten in place via \r.
+type barProgressReporter struct {
+	writer io.Writer
+
+	mutex     sync.Mutex
+	total     int64
+	startTime time.Time
+
+	transferred int64
+}
+
+// NewBarProgressReporter reports progress as a terminal bar written to writer.
+func NewBarProgressReporter(writer io.Writer) ProgressReporter {
+	return &barProgressReporter{writer: writer}
+}
+
+const barWidth = 30
+
+func (r *barProgressReporter) Start(total int64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.total = total
+	if r.startTime.IsZero() {
+		r.startTime = time.Now()
+	}
+}
+
+func (r *barProgressReporter) Advance(_ int, delta int64) {
+	r.render(atomic.AddInt64(&r.transferred, delta))
+}
+
+func (r *barProgressReporter) Done(err error) {
+	if err == nil {
+		r.render(atomic.LoadInt64(&r.transferred))
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	fmt.Fprintln(r.writer)
+}
+
+func (r *barProgressReporter) render(transferred int64) {
+	r.mutex.Lock()
+	total := r.total
+	r.mutex.Unlock()
+
+	filled := barWidth
+	percent := 100.0
+	if total > 0 {
+		percent = float64(transferred) / float64(total) * 100
+		filled = int(float64(barWidth) * float64(transferred) / float64(total))
+		if filled > barWidth {
+			filled = barWidth
+		}
+	}
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", barWidth-filled)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	fmt.Fprintf(r.writer, "\r[%s] %5.1f%% (%d/%d)", bar, percent, transferred, total)
+}
+
+// progressReader wraps an io.Reader and reports every chunk read to a ProgressReporter.
+type progressReader struct {
+	reader   io.Reader
+	index    int
+	reporter ProgressReporter
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.reporter.Advance(r.index, int64(n))
+	}
+	return n, err
+}
+
+// AggregatingReporter folds several concurrently-downloading artifacts' Start/Advance/Done calls into
+// one overall total reported to inner, so a batch of artifacts downloaded at the same time (see
+// downloadResolvedFiles) renders as a single progress stream instead of each artifact's Start
+// overwriting the last one's total and each artifact's Done firing a premature 100% event.
+type AggregatingReporter struct {
+	inner ProgressReporter
+
+	mutex    sync.Mutex
+	total    int64
+	pending  int
+	firstErr error
+}
+
+// NewAggregatingReporter returns an AggregatingReporter that reports the combined progress of every
+// artifact obtained through ForArtifact to inner.
+func NewAggregatingReporter(inner ProgressReporter) *AggregatingReporter {
+	return &AggregatingReporter{inner: inner}
+}
+
+// ForArtifact returns a ProgressReporter for one artifact in the batch; its Start grows the aggregate
+// total instead of replacing it, and inner.Done is only called once every artifact obtained via
+// ForArtifact has finished.
+func (a *AggregatingReporter) ForArtifact() ProgressReporter {
+	return &artifactProgressReporter{aggregate: a}
+}
+
+func (a *AggregatingReporter) startArtifact(total int64) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.total += total
+	a.pending++
+	a.inner.Start(a.total)
+}
+
+func (a *AggregatingReporter) doneArtifact(err error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if err != nil && a.firstErr == nil {
+		a.firstErr = err
+	}
+	a.pending--
+	if a.pending == 0 {
+		a.inner.Done(a.firstErr)
+	}
+}
+
+// artifactProgressReporter is the per-artifact view an AggregatingReporter hands out via ForArtifact.
+type artifactProgressReporter struct {
+	aggregate *AggregatingReporter
+}
+
+func (r *artifactProgressReporter) Start(total int64) {
+	r.aggregate.startArtifact(total)
+}
+
+func (r *artifactProgressReporter) Advance(partIndex int, delta int64) {
+	r.aggregate.inner.Advance(partIndex, delta)
+}
+
+func (r *artifactProgressReporter) Done(err error) {
+	r.aggregate.doneArtifact(err)
+}
@@ -0,0 +1,149 @@
+package download
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/develar/app-builder/pkg/util"
+	"github.com/develar/errors"
+)
+
+// qbittorrentBackend downloads .torrent/magnet: URLs through a running qBittorrent Web API
+// instance, for pulling huge Electron/Chromium archives over BitTorrent mirrors where HTTP is slow
+// or throttled.
+type qbittorrentBackend struct {
+	baseUrl string
+	// auth is "user:pass", empty if the Web UI has authentication disabled for the local subnet.
+	auth string
+}
+
+func newQbittorrentBackend(baseUrl string, auth string) Backend {
+	return qbittorrentBackend{baseUrl: strings.TrimRight(baseUrl, "/"), auth: auth}
+}
+
+func (b qbittorrentBackend) login(client *http.Client) error {
+	if b.auth == "" {
+		return nil
+	}
+
+	user, pass, ok := strings.Cut(b.auth, ":")
+	if !ok {
+		return errors.Errorf("invalid --qb-auth %q, expected user:pass", b.auth)
+	}
+
+	response, err := client.PostForm(b.baseUrl+"/api/v2/auth/login", url.Values{"username": {user}, "password": {pass}})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer util.Close(response.Body)
+
+	if response.StatusCode != http.StatusOK {
+		return errors.Errorf("qBittorrent login failed with status code %d", response.StatusCode)
+	}
+	return nil
+}
+
+func (b qbittorrentBackend) Download(torrentUrl string, output string, sha512 string) error {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	client := &http.Client{Jar: jar}
+
+	if err := b.login(client); err != nil {
+		return errors.WithStack(err)
+	}
+
+	tag := fmt.Sprintf("app-builder-%d", time.Now().UnixNano())
+	if err := b.addTorrent(client, torrentUrl, tag); err != nil {
+		return errors.WithStack(err)
+	}
+
+	contentPath, err := b.waitForCompletion(client, tag)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(output), 0777); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := os.Rename(contentPath, output); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(verifyFileSha512(output, sha512))
+}
+
+func (b qbittorrentBackend) addTorrent(client *http.Client, torrentUrl string, tag string) error {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	if err := writer.WriteField("urls", torrentUrl); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := writer.WriteField("tags", tag); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := writer.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	request, err := http.NewRequest(http.MethodPost, b.baseUrl+"/api/v2/torrents/add", &body)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+
+	response, err := client.Do(request)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer util.Close(response.Body)
+
+	if response.StatusCode != http.StatusOK {
+		return errors.Errorf("qBittorrent add torrent failed with status code %d", response.StatusCode)
+	}
+	return nil
+}
+
+// waitForCompletion polls qBittorrent for the torrent it just tagged until it finishes downloading
+// (enters a seeding state) or fails, returning the path it downloaded the content to.
+func (b qbittorrentBackend) waitForCompletion(client *http.Client, tag string) (string, error) {
+	type torrentInfo struct {
+		State       string `json:"state"`
+		ContentPath string `json:"content_path"`
+	}
+
+	for {
+		response, err := client.Get(b.baseUrl + "/api/v2/torrents/info?tag=" + url.QueryEscape(tag))
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+
+		var torrents []torrentInfo
+		decodeErr := json.NewDecoder(response.Body).Decode(&torrents)
+		util.Close(response.Body)
+		if decodeErr != nil {
+			return "", errors.WithStack(decodeErr)
+		}
+
+		if len(torrents) > 0 {
+			switch torrents[0].State {
+			case "error", "missingFiles":
+				return "", errors.Errorf("qBittorrent download failed, state %s", torrents[0].State)
+			case "uploading", "stalledUP", "queuedUP", "pausedUP", "forcedUP", "checkingUP":
+				return torrents[0].ContentPath, nil
+			}
+		}
+
+		time.Sleep(2 * time.Second)
+	}
+}
@@ -0,0 +1,98 @@
+package download
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/develar/errors"
+	"golang.org/x/sync/errgroup"
+)
+
+// ResolvedFile is one entry of a download-resolved-files manifest.
+type ResolvedFile struct {
+	Url    string `json:"url"`
+	Output string `json:"output"`
+	Sha512 string `json:"sha512"`
+}
+
+func ConfigureDownloadResolvedFilesCommand(app *kingpin.Application) {
+	command := app.Command("download-resolved-files", "Download a batch of already-resolved files concurrently.")
+	configFile := command.Flag("config", "JSON file with an array of {url, output, sha512} entries.").Short('c').Required().String()
+	maxConcurrentArtifacts := command.Flag("max-concurrent-artifacts", "Maximum number of artifacts downloaded at the same time.").Default("0").Int()
+	progressKind := addProgressFlag(command)
+	progressFd := addProgressFdFlag(command)
+
+	command.Action(func(context *kingpin.ParseContext) error {
+		data, err := ioutil.ReadFile(*configFile)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		var files []ResolvedFile
+		err = json.Unmarshal(data, &files)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		downloader := NewDownloader().WithProgress(configureProgressReporter(*progressKind, *progressFd))
+		return errors.WithStack(downloadResolvedFiles(downloader, files, *maxConcurrentArtifacts))
+	})
+}
+
+// downloadResolvedFiles downloads every file concurrently through a single shared Downloader, bounded
+// by maxConcurrentArtifacts (runtime.NumCPU() when <= 0), cancelling the rest as soon as one fails.
+// Each artifact reports through its own view of an AggregatingReporter so the whole batch renders as
+// one progress stream instead of concurrent artifacts stomping on each other's Start/Done calls.
+func downloadResolvedFiles(downloader Downloader, files []ResolvedFile, maxConcurrentArtifacts int) error {
+	if maxConcurrentArtifacts <= 0 {
+		maxConcurrentArtifacts = runtime.NumCPU()
+	}
+
+	scheduler := newHostScheduler(files)
+	aggregateProgress := NewAggregatingReporter(downloader.progress)
+	semaphore := make(chan struct{}, maxConcurrentArtifacts)
+	group, groupContext := errgroup.WithContext(context.Background())
+
+	for _, file := range files {
+		file := file
+
+		select {
+		case semaphore <- struct{}{}:
+		case <-groupContext.Done():
+			return errors.WithStack(group.Wait())
+		}
+
+		group.Go(func() error {
+			defer func() { <-semaphore }()
+			fileDownloader := downloader.WithProgress(aggregateProgress.ForArtifact())
+			return fileDownloader.downloadResolvedFile(groupContext, file, scheduler.budgetFor(file.Url))
+		})
+	}
+
+	return errors.WithStack(group.Wait())
+}
+
+// downloadResolvedFile downloads a single manifest entry, capping its own part concurrency to the
+// host's shared budget.
+func (t Downloader) downloadResolvedFile(ctx context.Context, file ResolvedFile, hostBudget chan struct{}) error {
+	if err := os.MkdirAll(filepath.Dir(file.Output), 0777); err != nil {
+		return errors.WithStack(err)
+	}
+
+	location, err := t.resolveForDownload(file.Url, file.Output)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	maxParts := getMaxPartCount()
+	if hostBudget != nil && cap(hostBudget) < maxParts {
+		maxParts = cap(hostBudget)
+	}
+
+	return t.downloadResolved(ctx, location, file.Sha512, maxParts, hostBudget)
+}
@@ -0,0 +1,48 @@
+package download
+
+import (
+	"net/url"
+	"sync"
+)
+
+// hostScheduler coalesces the part-level concurrency of artifacts that share a host. Every host gets
+// one shared budget, regardless of how many artifacts are downloading from it at the same time.
+type hostScheduler struct {
+	mutex   sync.Mutex
+	budgets map[string]chan struct{}
+}
+
+func newHostScheduler(files []ResolvedFile) *hostScheduler {
+	artifactsPerHost := make(map[string]int)
+	for _, file := range files {
+		artifactsPerHost[hostOf(file.Url)]++
+	}
+
+	scheduler := &hostScheduler{budgets: make(map[string]chan struct{}, len(artifactsPerHost))}
+	for host, artifactCount := range artifactsPerHost {
+		budget := maxIdleConnsPerHost / artifactCount
+		if budget > getMaxPartCount() {
+			budget = getMaxPartCount()
+		}
+		if budget < 1 {
+			budget = 1
+		}
+		scheduler.budgets[host] = make(chan struct{}, budget)
+	}
+	return scheduler
+}
+
+// budgetFor returns the shared part-request budget for rawUrl's host.
+func (s *hostScheduler) budgetFor(rawUrl string) chan struct{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.budgets[hostOf(rawUrl)]
+}
+
+func hostOf(rawUrl string) string {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return rawUrl
+	}
+	return parsed.Host
+}
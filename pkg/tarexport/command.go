@@ -0,0 +1,40 @@
+package tarexport
+
+import (
+	"os"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/develar/errors"
+)
+
+// ConfigureCommand registers the "tar" command, a reproducible tar exporter mirroring the "copy"
+// command's flag style.
+func ConfigureCommand(app *kingpin.Application) {
+	command := app.Command("tar", "Write a directory to a reproducible tar archive.")
+
+	from := command.Flag("from", "Directory to archive.").Required().Short('f').String()
+	output := command.Flag("output", "Output archive file.").Required().Short('o').String()
+	compression := command.Flag("compression", "Compression, one of: none, gzip, zstd, xz. Defaults from --output's extension.").String()
+	mtime := command.Flag("mtime", "SOURCE_DATE_EPOCH: Unix time every entry's mtime is clamped to.").Int64()
+	preserveXattrs := command.Flag("xattrs", "Preserve extended attributes as PAX records.").Bool()
+
+	command.Action(func(context *kingpin.ParseContext) error {
+		compressionKind := *compression
+		if compressionKind == "" {
+			compressionKind = CompressionFromExtension(*output)
+		}
+
+		outFile, err := os.Create(*output)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		defer outFile.Close()
+
+		_, err = Write(*from, outFile, Options{
+			SourceDateEpoch: *mtime,
+			Compression:     compressionKind,
+			PreserveXattrs:  *preserveXattrs,
+		})
+		return errors.WithStack(err)
+	})
+}
@@ -0,0 +1,52 @@
+package tarexport
+
+import (
+	"compress/gzip"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/develar/errors"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// wrapCompression returns a writer that compresses whatever is written to it per kind, and a close
+// func that must be called to flush the compressor's trailer.
+func wrapCompression(w io.Writer, kind string) (io.Writer, func() error, error) {
+	switch kind {
+	case "", "none":
+		return w, func() error { return nil }, nil
+	case "gzip":
+		gzipWriter := gzip.NewWriter(w)
+		return gzipWriter, gzipWriter.Close, nil
+	case "zstd":
+		zstdWriter, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+		return zstdWriter, zstdWriter.Close, nil
+	case "xz":
+		xzWriter, err := xz.NewWriter(w)
+		if err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+		return xzWriter, xzWriter.Close, nil
+	default:
+		return nil, nil, errors.Errorf("unknown compression %q", kind)
+	}
+}
+
+// CompressionFromExtension guesses the on-the-fly Options.Compression from an output file name.
+func CompressionFromExtension(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".zst", ".zstd":
+		return "zstd"
+	case ".xz":
+		return "xz"
+	case ".gz", ".tgz":
+		return "gzip"
+	default:
+		return "none"
+	}
+}
@@ -0,0 +1,24 @@
+//go:build linux || darwin
+
+package tarexport
+
+import (
+	"os"
+	"syscall"
+)
+
+// dedupeHardlink returns (firstPath, true) the second and later times a given (dev, inode) pair is seen.
+func dedupeHardlink(info os.FileInfo, relPath string, seen map[inodeKey]string) (string, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || stat.Nlink <= 1 {
+		return "", false
+	}
+
+	key := inodeKey{dev: uint64(stat.Dev), ino: stat.Ino}
+	if target, ok := seen[key]; ok {
+		return target, true
+	}
+
+	seen[key] = relPath
+	return "", false
+}
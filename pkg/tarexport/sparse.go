@@ -0,0 +1,64 @@
+package tarexport
+
+import (
+	"io"
+	"os"
+	"runtime"
+)
+
+// seekData and seekHole are the lseek whence values of SEEK_DATA and SEEK_HOLE: 3 and 4 on Linux,
+// swapped on darwin.
+var seekData, seekHole = 3, 4
+
+func init() {
+	if runtime.GOOS == "darwin" {
+		seekData, seekHole = 4, 3
+	}
+}
+
+// sparseHole is a hole (a run of logical zero bytes) in a file, as reported by SEEK_HOLE.
+type sparseHole struct {
+	offset int64
+	length int64
+}
+
+// detectSparseHoles returns file's hole map if the underlying filesystem supports SEEK_DATA /
+// SEEK_HOLE, or a nil slice and the seek error if it doesn't.
+func detectSparseHoles(file *os.File, size int64) ([]sparseHole, error) {
+	if size == 0 {
+		return nil, nil
+	}
+
+	var holes []sparseHole
+	pos := int64(0)
+	for pos < size {
+		dataStart, err := file.Seek(pos, seekData)
+		if err != nil {
+			// ENXIO (no more data past pos) or an unsupported filesystem: treat as non-sparse.
+			return nil, err
+		}
+		if dataStart > size {
+			dataStart = size
+		}
+		if dataStart > pos {
+			holes = append(holes, sparseHole{offset: pos, length: dataStart - pos})
+		}
+		if dataStart >= size {
+			break
+		}
+
+		dataEnd, err := file.Seek(dataStart, seekHole)
+		if err != nil {
+			return nil, err
+		}
+		if dataEnd > size {
+			dataEnd = size
+		}
+		pos = dataEnd
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return holes, nil
+}
@@ -0,0 +1,78 @@
+package tarexport
+
+import (
+	"archive/tar"
+	"io"
+
+	"github.com/develar/errors"
+)
+
+// Options configures Write.
+type Options struct {
+	// SourceDateEpoch clamps every entry's mtime to this Unix time for reproducible output
+	// (https://reproducible-builds.org/docs/source-date-epoch/). Zero disables clamping.
+	SourceDateEpoch int64
+
+	// Compression selects the on-the-fly compression applied to the tar stream: "", "none", "gzip",
+	// "zstd" or "xz". Callers that want extension-based defaulting (like the tar CLI command) resolve
+	// that themselves before calling Write.
+	Compression string
+
+	// PreserveXattrs copies each file's extended attributes into PAX records. Off by default since
+	// most callers (blockmap, distro packers) don't need them and xattrs aren't portable across OSes.
+	PreserveXattrs bool
+}
+
+// Entry describes one regular file written to the tar.
+type Entry struct {
+	Path   string
+	Size   int64
+	Sha256 string
+	// Offset is this entry's data's byte position in the uncompressed tar stream. It only corresponds
+	// to a position in the file Write actually produced when opts.Compression is "" or "none" —
+	// compressing writers don't preserve input byte offsets, so callers that need to slice a
+	// compressed output file (e.g. blockmap.BuildBlockMap) must request Compression: "none".
+	Offset int64
+}
+
+// Manifest is returned by Write and lists every regular file written to the tar, in write order.
+type Manifest []Entry
+
+// Write walks root and writes every directory, regular file and symlink under it (other file types
+// are skipped) to w as a reproducible PAX tar: directory entries
+// sorted by path, uid/gid forced to 0, mtime clamped per opts.SourceDateEpoch. See Entry.Offset for a
+// caveat about what the returned Manifest's offsets mean when opts.Compression is set.
+func Write(root string, w io.Writer, opts Options) (Manifest, error) {
+	compressedWriter, closeCompression, err := wrapCompression(w, opts.Compression)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	offsetWriter := &countingWriter{writer: compressedWriter}
+	tarWriter := tar.NewWriter(offsetWriter)
+
+	manifest, err := writeTree(tarWriter, offsetWriter, root, opts)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := closeCompression(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return manifest, nil
+}
+
+// countingWriter tracks how many bytes have been written to the (pre-compression) tar stream.
+type countingWriter struct {
+	writer io.Writer
+	count  int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.count += int64(n)
+	return n, err
+}
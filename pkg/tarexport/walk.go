@@ -0,0 +1,207 @@
+package tarexport
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/develar/errors"
+)
+
+type inodeKey struct {
+	dev uint64
+	ino uint64
+}
+
+// writeTree walks root in sorted order and writes every entry to tarWriter, returning the manifest
+// of regular files written.
+func writeTree(tarWriter *tar.Writer, offsetWriter *countingWriter, root string, opts Options) (Manifest, error) {
+	paths, err := walkSorted(root)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	hardlinkTargets := make(map[inodeKey]string)
+	var manifest Manifest
+
+	for _, relPath := range paths {
+		absPath := filepath.Join(root, relPath)
+
+		info, err := os.Lstat(absPath)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		header.Name = relPath
+		header.Uid = 0
+		header.Gid = 0
+		header.Uname = ""
+		header.Gname = ""
+		if opts.SourceDateEpoch > 0 {
+			epoch := time.Unix(opts.SourceDateEpoch, 0)
+			if header.ModTime.After(epoch) {
+				header.ModTime = epoch
+			}
+		}
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(absPath)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			header.Typeflag = tar.TypeSymlink
+			header.Linkname = target
+			if err := tarWriter.WriteHeader(header); err != nil {
+				return nil, errors.WithStack(err)
+			}
+
+		case info.IsDir():
+			header.Typeflag = tar.TypeDir
+			if err := tarWriter.WriteHeader(header); err != nil {
+				return nil, errors.WithStack(err)
+			}
+
+		case info.Mode().IsRegular():
+			if linkTarget, isHardlink := dedupeHardlink(info, relPath, hardlinkTargets); isHardlink {
+				header.Typeflag = tar.TypeLink
+				header.Linkname = linkTarget
+				header.Size = 0
+				if err := tarWriter.WriteHeader(header); err != nil {
+					return nil, errors.WithStack(err)
+				}
+				continue
+			}
+
+			if opts.PreserveXattrs {
+				if records, err := readXattrs(absPath); err == nil && len(records) > 0 {
+					header.PAXRecords = records
+				}
+			}
+
+			entry, err := writeRegularFile(tarWriter, offsetWriter, absPath, relPath, header)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			manifest = append(manifest, *entry)
+		}
+	}
+
+	return manifest, nil
+}
+
+func writeRegularFile(tarWriter *tar.Writer, offsetWriter *countingWriter, absPath string, relPath string, header *tar.Header) (*Entry, error) {
+	src, err := os.Open(absPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer src.Close()
+
+	// The stdlib tar.Writer can't emit a GNU sparse header, so holes are still written out as literal
+	// zero bytes; detecting them only lets us skip reading those regions back from disk.
+	holes, err := detectSparseHoles(src, header.Size)
+	if err != nil {
+		holes = nil
+	}
+
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	offsetBeforeData := offsetWriter.count
+
+	hash := sha256.New()
+	dest := io.MultiWriter(tarWriter, hash)
+	if err := copySparse(dest, src, holes, header.Size); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &Entry{
+		Path:   relPath,
+		Size:   header.Size,
+		Sha256: hex.EncodeToString(hash.Sum(nil)),
+		Offset: offsetBeforeData,
+	}, nil
+}
+
+// copySparse writes size logical bytes of src to dest, skipping disk reads for the given holes and
+// writing zeroes for them instead.
+func copySparse(dest io.Writer, src io.ReadSeeker, holes []sparseHole, size int64) error {
+	if len(holes) == 0 {
+		_, err := io.CopyN(dest, src, size)
+		return err
+	}
+
+	pos := int64(0)
+	for _, hole := range holes {
+		if hole.offset > pos {
+			if _, err := io.CopyN(dest, src, hole.offset-pos); err != nil {
+				return err
+			}
+		}
+		if err := writeZeroes(dest, hole.length); err != nil {
+			return err
+		}
+		pos = hole.offset + hole.length
+		if _, err := src.Seek(pos, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	if pos < size {
+		if _, err := io.CopyN(dest, src, size-pos); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeZeroes(dest io.Writer, count int64) error {
+	zeroes := make([]byte, 32*1024)
+	for count > 0 {
+		chunk := int64(len(zeroes))
+		if count < chunk {
+			chunk = count
+		}
+		if _, err := dest.Write(zeroes[:chunk]); err != nil {
+			return err
+		}
+		count -= chunk
+	}
+	return nil
+}
+
+func walkSorted(root string) ([]string, error) {
+	var paths []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		paths = append(paths, relPath)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	sort.Strings(paths)
+	return paths, nil
+}
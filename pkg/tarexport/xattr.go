@@ -0,0 +1,17 @@
+package tarexport
+
+// splitNulTerminated splits a NUL-separated byte buffer (as returned by listxattr) into individual
+// strings, dropping any trailing empty segment.
+func splitNulTerminated(buf []byte) []string {
+	var names []string
+	start := 0
+	for i, b := range buf {
+		if b == 0 {
+			if i > start {
+				names = append(names, string(buf[start:i]))
+			}
+			start = i + 1
+		}
+	}
+	return names
+}
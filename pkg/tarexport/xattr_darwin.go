@@ -0,0 +1,39 @@
+//go:build darwin
+
+package tarexport
+
+import "golang.org/x/sys/unix"
+
+// readXattrs reads path's extended attributes and encodes them as PAX records using the same
+// "SCHILY.xattr.<name>" convention GNU tar and libarchive use. darwin's syscall package doesn't
+// expose Listxattr/Getxattr, so this goes through golang.org/x/sys/unix instead.
+func readXattrs(path string) (map[string]string, error) {
+	size, err := unix.Listxattr(path, nil)
+	if err != nil || size == 0 {
+		return nil, err
+	}
+
+	nameBuf := make([]byte, size)
+	size, err = unix.Listxattr(path, nameBuf)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make(map[string]string)
+	for _, name := range splitNulTerminated(nameBuf[:size]) {
+		valueSize, err := unix.Getxattr(path, name, nil)
+		if err != nil || valueSize == 0 {
+			continue
+		}
+
+		value := make([]byte, valueSize)
+		valueSize, err = unix.Getxattr(path, name, value)
+		if err != nil {
+			continue
+		}
+
+		records["SCHILY.xattr."+name] = string(value[:valueSize])
+	}
+
+	return records, nil
+}
@@ -0,0 +1,38 @@
+//go:build linux
+
+package tarexport
+
+import "syscall"
+
+// readXattrs reads path's extended attributes and encodes them as PAX records using the same
+// "SCHILY.xattr.<name>" convention GNU tar and libarchive use.
+func readXattrs(path string) (map[string]string, error) {
+	size, err := syscall.Listxattr(path, nil)
+	if err != nil || size == 0 {
+		return nil, err
+	}
+
+	nameBuf := make([]byte, size)
+	size, err = syscall.Listxattr(path, nameBuf)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make(map[string]string)
+	for _, name := range splitNulTerminated(nameBuf[:size]) {
+		valueSize, err := syscall.Getxattr(path, name, nil)
+		if err != nil || valueSize == 0 {
+			continue
+		}
+
+		value := make([]byte, valueSize)
+		valueSize, err = syscall.Getxattr(path, name, value)
+		if err != nil {
+			continue
+		}
+
+		records["SCHILY.xattr."+name] = string(value[:valueSize])
+	}
+
+	return records, nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package tarexport
+
+// readXattrs is a no-op on platforms without a portable xattr syscall (e.g. windows): PreserveXattrs
+// is silently ignored there.
+func readXattrs(path string) (map[string]string, error) {
+	return nil, nil
+}